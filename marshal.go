@@ -0,0 +1,278 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// A LeafCodec turns leafs into bytes and back, so a Specification can be
+// persisted or sent across a process or language boundary.
+type LeafCodec[Leaf any] interface {
+
+	// EncodeLeaf turns a leaf into bytes.
+	EncodeLeaf(Leaf) ([]byte, error)
+
+	// DecodeLeaf turns bytes produced by EncodeLeaf back into a leaf.
+	DecodeLeaf([]byte) (Leaf, error)
+}
+
+// ErrInvalidSpec is returned by Unmarshal and UnmarshalJSON when the given
+// data does not decode into a well-formed Specification.
+type ErrInvalidSpec struct {
+
+	// Offset locates the problem. For a malformed opcode stream it is the
+	// index of the offending op within it; for data Unmarshal cannot read
+	// it is the byte offset within data where reading stopped.
+	// UnmarshalJSON reports 0 for problems outside the opcode stream.
+	Offset int
+
+	// Reason describes what is wrong with the data at Offset.
+	Reason string
+}
+
+func (e *ErrInvalidSpec) Error() string {
+	return fmt.Sprintf("specifications: invalid spec at offset %d: %s", e.Offset, e.Reason)
+}
+
+const _marshalVersion = 1
+
+var errTruncated = errors.New("specifications: truncated data")
+
+// Marshal encodes s as a versioned binary stream: the opcode vector
+// followed by each leaf's payload, as produced by c.EncodeLeaf.
+//
+// Marshal panics if s is a zero specification.
+func Marshal[Leaf any](s Specification[Leaf], c LeafCodec[Leaf]) ([]byte, error) {
+	if s.Zero() {
+		panic("Marshal: cannot use zero spec")
+	}
+
+	buf := []byte{_marshalVersion}
+
+	buf = binary.AppendUvarint(buf, uint64(len(s.ops)))
+	for _, op := range s.ops {
+		buf = binary.AppendUvarint(buf, uint64(op))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(s.leafs)))
+	for _, leaf := range s.leafs {
+		enc, err := c.EncodeLeaf(leaf)
+		if err != nil {
+			return nil, fmt.Errorf("specifications: encoding leaf: %w", err)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(enc)))
+		buf = append(buf, enc...)
+	}
+
+	return buf, nil
+}
+
+// Unmarshal decodes data produced by Marshal back into a Specification.
+//
+// data did not necessarily come from Marshal, so Unmarshal validates the
+// opcode grammar before trusting it: leaf indices must be in range, And/Or
+// must combine at least one value, and the stream must leave exactly one
+// value on the stack. Any violation is reported as an *ErrInvalidSpec
+// pinpointing the offset where it was found.
+func Unmarshal[Leaf any](data []byte, c LeafCodec[Leaf]) (Specification[Leaf], error) {
+	r := &_byteReader{data: data}
+
+	version, err := r.uvarint()
+	if err != nil {
+		return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "truncated version"}
+	}
+	if version != _marshalVersion {
+		return Specification[Leaf]{}, &ErrInvalidSpec{0, fmt.Sprintf("unsupported version %d", version)}
+	}
+
+	opCount, err := r.uvarint()
+	if err != nil {
+		return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "truncated op count"}
+	}
+	if opCount > uint64(len(r.data)-r.offset) {
+		return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "op count exceeds remaining data"}
+	}
+
+	ops := make([]_OpCode, opCount)
+	for i := range ops {
+		v, err := r.uvarint()
+		if err != nil {
+			return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "truncated ops"}
+		}
+		ops[i] = _OpCode(v)
+	}
+
+	leafCount, err := r.uvarint()
+	if err != nil {
+		return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "truncated leaf count"}
+	}
+	if leafCount > uint64(len(r.data)-r.offset) {
+		return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "leaf count exceeds remaining data"}
+	}
+
+	leafs := make([]Leaf, leafCount)
+	for i := range leafs {
+		n, err := r.uvarint()
+		if err != nil {
+			return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "truncated leaf length"}
+		}
+		raw, err := r.bytes(int(n))
+		if err != nil {
+			return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, "truncated leaf payload"}
+		}
+		leaf, err := c.DecodeLeaf(raw)
+		if err != nil {
+			return Specification[Leaf]{}, &ErrInvalidSpec{r.offset, fmt.Sprintf("decoding leaf %d: %s", i, err)}
+		}
+		leafs[i] = leaf
+	}
+
+	if err := validateOps(ops, len(leafs)); err != nil {
+		return Specification[Leaf]{}, err
+	}
+
+	return Specification[Leaf]{ops: ops, leafs: leafs}, nil
+}
+
+// validateOps replays the same stack discipline Evaluate uses, without
+// calling into an evaluator, to catch malformed opcode streams before they
+// are trusted.
+func validateOps(ops []_OpCode, leafCount int) error {
+	stack := 0
+	i := 0
+
+	for i < len(ops) {
+		switch ops[i] {
+
+		case _OpCodeLeaf:
+			if i+1 >= len(ops) {
+				return &ErrInvalidSpec{i, "Leaf op is missing its index"}
+			}
+			leafIx := int(ops[i+1])
+			if leafIx < 0 || leafIx >= leafCount {
+				return &ErrInvalidSpec{i, "leaf index out of range"}
+			}
+			stack++
+			i += 2
+
+		case _OpCodeNot:
+			if stack < 1 {
+				return &ErrInvalidSpec{i, "Not needs a value on the stack"}
+			}
+			i++
+
+		case _OpCodeAnd, _OpCodeOr:
+			if i+1 >= len(ops) {
+				return &ErrInvalidSpec{i, "And/Or op is missing its argument count"}
+			}
+			argCount := int(ops[i+1])
+			if argCount < 1 {
+				return &ErrInvalidSpec{i, "And/Or needs at least one argument"}
+			}
+			if stack < argCount {
+				return &ErrInvalidSpec{i, "And/Or needs more values than are on the stack"}
+			}
+			stack -= argCount
+			stack++
+			i += 2
+
+		default:
+			return &ErrInvalidSpec{i, "unknown opcode"}
+		}
+	}
+
+	if stack != 1 {
+		return &ErrInvalidSpec{len(ops), "stream does not leave exactly one value on the stack"}
+	}
+	return nil
+}
+
+type _byteReader struct {
+	data   []byte
+	offset int
+}
+
+func (r *_byteReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.offset:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.offset += n
+	return v, nil
+}
+
+func (r *_byteReader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.offset {
+		return nil, errTruncated
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b, nil
+}
+
+// _jsonSpec is the wire shape MarshalJSON/UnmarshalJSON use. Leafs are kept
+// as raw JSON so their shape stays caller-defined.
+type _jsonSpec struct {
+	Version int               `json:"version"`
+	Ops     []_OpCode         `json:"ops"`
+	Leafs   []json.RawMessage `json:"leafs"`
+}
+
+// MarshalJSON encodes s as JSON, using c to turn each leaf into a JSON
+// value.
+//
+// MarshalJSON panics if s is a zero specification.
+func MarshalJSON[Leaf any](s Specification[Leaf], c LeafCodec[Leaf]) ([]byte, error) {
+	if s.Zero() {
+		panic("MarshalJSON: cannot use zero spec")
+	}
+
+	leafs := make([]json.RawMessage, len(s.leafs))
+	for i, leaf := range s.leafs {
+		enc, err := c.EncodeLeaf(leaf)
+		if err != nil {
+			return nil, fmt.Errorf("specifications: encoding leaf: %w", err)
+		}
+		leafs[i] = json.RawMessage(enc)
+	}
+
+	return json.Marshal(_jsonSpec{
+		Version: _marshalVersion,
+		Ops:     s.ops,
+		Leafs:   leafs,
+	})
+}
+
+// UnmarshalJSON decodes data produced by MarshalJSON back into a
+// Specification, validating the opcode grammar the same way Unmarshal
+// does.
+func UnmarshalJSON[Leaf any](data []byte, c LeafCodec[Leaf]) (Specification[Leaf], error) {
+	var js _jsonSpec
+	if err := json.Unmarshal(data, &js); err != nil {
+		return Specification[Leaf]{}, &ErrInvalidSpec{0, fmt.Sprintf("malformed JSON: %s", err)}
+	}
+	if js.Version != _marshalVersion {
+		return Specification[Leaf]{}, &ErrInvalidSpec{0, fmt.Sprintf("unsupported version %d", js.Version)}
+	}
+
+	leafs := make([]Leaf, len(js.Leafs))
+	for i, raw := range js.Leafs {
+		leaf, err := c.DecodeLeaf([]byte(raw))
+		if err != nil {
+			return Specification[Leaf]{}, &ErrInvalidSpec{0, fmt.Sprintf("decoding leaf %d: %s", i, err)}
+		}
+		leafs[i] = leaf
+	}
+
+	if err := validateOps(js.Ops, len(leafs)); err != nil {
+		return Specification[Leaf]{}, err
+	}
+
+	return Specification[Leaf]{ops: js.Ops, leafs: leafs}, nil
+}
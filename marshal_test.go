@@ -0,0 +1,141 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/szabba/assert"
+
+	"github.com/szabba/specifications"
+)
+
+type boolCodec struct{}
+
+func (boolCodec) EncodeLeaf(v bool) ([]byte, error) {
+	if v {
+		return []byte{1}, nil
+	}
+	return []byte{0}, nil
+}
+
+func (boolCodec) DecodeLeaf(b []byte) (bool, error) {
+	if len(b) != 1 {
+		return false, fmt.Errorf("want 1 byte, got %d", len(b))
+	}
+	return b[0] != 0, nil
+}
+
+func TestMarshalRoundTrips(t *testing.T) {
+	tts := map[string]specifications.Specification[bool]{
+		"leaf":           leaf(true),
+		"not":            not(leaf(false)),
+		"and":            and(leaf(true), leaf(false)),
+		"or":             or(leaf(true), leaf(false)),
+		"reencodedNots":  and(not(leaf(true))),
+		"reencodedAndOr": and(and(leaf(true)), or(leaf(false), leaf(true))),
+	}
+
+	for name, spec := range tts {
+		t.Run(name, func(t *testing.T) {
+			// given
+			want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+
+			// when
+			data, err := specifications.Marshal[bool](spec, boolCodec{})
+			assert.That(err == nil, t.Fatalf, "Marshal: unexpected error: %s", err)
+
+			got, err := specifications.Unmarshal[bool](data, boolCodec{})
+			assert.That(err == nil, t.Fatalf, "Unmarshal: unexpected error: %s", err)
+
+			// then
+			gotOut := specifications.Evaluate[bool, bool](got, Evaluator{})
+			assert.That(gotOut == want, t.Errorf, "round trip changed the value from %v to %v", want, gotOut)
+		})
+	}
+}
+
+type jsonBoolCodec struct{}
+
+func (jsonBoolCodec) EncodeLeaf(v bool) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonBoolCodec) DecodeLeaf(b []byte) (bool, error) {
+	var v bool
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+func TestMarshalJSONRoundTrips(t *testing.T) {
+	// given
+	spec := and(or(leaf(true), leaf(false)), not(leaf(false)))
+	want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+
+	// when
+	data, err := specifications.MarshalJSON(spec, jsonBoolCodec{})
+	assert.That(err == nil, t.Fatalf, "MarshalJSON: unexpected error: %s", err)
+
+	got, err := specifications.UnmarshalJSON[bool](data, jsonBoolCodec{})
+	assert.That(err == nil, t.Fatalf, "UnmarshalJSON: unexpected error: %s", err)
+
+	// then
+	gotOut := specifications.Evaluate[bool, bool](got, Evaluator{})
+	assert.That(gotOut == want, t.Errorf, "round trip changed the value from %v to %v", want, gotOut)
+}
+
+func TestUnmarshalRejectsMalformedStreams(t *testing.T) {
+	tts := map[string][]byte{
+		"emptyData":           {},
+		"leafIndexOutOfRange": {1, 2, 1, 5, 1, 1, 1},
+		"andWithZeroArgs":     {1, 4, 1, 0, 3, 0, 1, 1, 1},
+		"extraValueOnStack":   {1, 4, 1, 0, 1, 0, 1, 1, 1, 1, 2, 1},
+		"truncatedOp":         {1, 1},
+		"unsupportedVersion":  {99, 0, 0},
+	}
+
+	for name, data := range tts {
+		t.Run(name, func(t *testing.T) {
+			// given
+
+			// when
+			_, err := specifications.Unmarshal[bool](data, boolCodec{})
+
+			// then
+			var invalid *specifications.ErrInvalidSpec
+			assert.That(errors.As(err, &invalid), t.Errorf, "got %v, want an *ErrInvalidSpec", err)
+		})
+	}
+}
+
+func TestMarshalPanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[bool]
+
+	// when
+	p := catchPanic(func() { specifications.Marshal[bool](zero, boolCodec{}) })
+
+	// then
+	msg := "Marshal: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	seed, err := specifications.Marshal[bool](and(leaf(true), not(or(leaf(false), leaf(true)))), boolCodec{})
+	if err != nil {
+		f.Fatalf("building seed corpus: %s", err)
+	}
+	f.Add(seed)
+	f.Add([]byte{})
+	f.Add([]byte{1, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Unmarshal must never panic, no matter how malformed data is.
+		specifications.Unmarshal[bool](data, boolCodec{})
+	})
+}
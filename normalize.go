@@ -0,0 +1,452 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications
+
+import "errors"
+
+// ErrTooLarge is returned by CNF and DNF when producing the normal form
+// would need more than the given maxClauses top-level clauses.
+var ErrTooLarge = errors.New("specifications: normal form exceeds maxClauses")
+
+// _Node is an in-memory tree view of a Specification's opcode stream.
+//
+// CNF, DNF and Simplify decode into this shape to compute a genuine
+// structural rewrite - distributing Or over And, flattening and deduping
+// clauses - that can't be read directly off the opcode stream, then
+// re-encode it through Leaf/Not/And/Or.
+//
+// NNF doesn't use _Node: since it never changes a spec's arity or leaf
+// count, it rewrites the opcode/leaf streams directly; see emitNNF.
+type _Node[Leaf any] struct {
+	kind     _OpCode
+	leaf     Leaf
+	children []_Node[Leaf]
+}
+
+// decode turns s's opcode stream into a _Node tree, using the same stack
+// discipline as Evaluate.
+func decode[Leaf any](s Specification[Leaf]) _Node[Leaf] {
+	opsLeft, stack := s.ops, make([]_Node[Leaf], 0, 20)
+
+	for len(opsLeft) > 0 {
+
+		switch opsLeft[0] {
+
+		case _OpCodeLeaf:
+			leafIx := int(opsLeft[1])
+			stack = append(stack, _Node[Leaf]{kind: _OpCodeLeaf, leaf: s.leafs[leafIx]})
+			opsLeft = opsLeft[2:]
+
+		case _OpCodeNot:
+			top, rest := stack[len(stack)-1], stack[:len(stack)-1]
+			stack = append(rest, _Node[Leaf]{kind: _OpCodeNot, children: []_Node[Leaf]{top}})
+			opsLeft = opsLeft[1:]
+
+		case _OpCodeAnd, _OpCodeOr:
+			argCount := int(opsLeft[1])
+			top, rest := pickTop(stack, argCount)
+			children := append([]_Node[Leaf]{}, top...)
+			stack = append(rest, _Node[Leaf]{kind: opsLeft[0], children: children})
+			opsLeft = opsLeft[2:]
+		}
+	}
+
+	return stack[0]
+}
+
+// encode rebuilds a Specification from a _Node tree via the public
+// constructors, so the result always goes through the same validation and
+// preallocation they already do.
+func encode[Leaf any](n _Node[Leaf]) Specification[Leaf] {
+	switch n.kind {
+
+	case _OpCodeLeaf:
+		return Specification[Leaf]{
+			leafs: []Leaf{n.leaf},
+			ops:   []_OpCode{_OpCodeLeaf, _OpCode(0)},
+		}
+
+	case _OpCodeNot:
+		return Not(encode(n.children[0]))
+
+	case _OpCodeAnd:
+		return And(encodeAll(n.children)...)
+
+	case _OpCodeOr:
+		return Or(encodeAll(n.children)...)
+	}
+
+	panic("encode: unknown node kind")
+}
+
+func encodeAll[Leaf any](ns []_Node[Leaf]) []Specification[Leaf] {
+	out := make([]Specification[Leaf], len(ns))
+	for i, n := range ns {
+		out[i] = encode(n)
+	}
+	return out
+}
+
+func dual(op _OpCode) _OpCode {
+	switch op {
+	case _OpCodeAnd:
+		return _OpCodeOr
+	case _OpCodeOr:
+		return _OpCodeAnd
+	}
+	panic("dual: op is neither And nor Or")
+}
+
+// _span locates a node within an original opcode stream without copying
+// its leafs, so a rewrite that doesn't change arity - like NNF - can be
+// driven straight off it instead of through a leaf-carrying _Node tree.
+type _span struct {
+	kind     _OpCode
+	leafIx   int // valid when kind == _OpCodeLeaf
+	children []_span
+}
+
+// decodeSpans finds ops' structure using the same stack discipline as
+// Evaluate, without touching the leafs it refers to.
+func decodeSpans(ops []_OpCode) _span {
+	opsLeft, stack := ops, make([]_span, 0, 20)
+
+	for len(opsLeft) > 0 {
+
+		switch opsLeft[0] {
+
+		case _OpCodeLeaf:
+			stack = append(stack, _span{kind: _OpCodeLeaf, leafIx: int(opsLeft[1])})
+			opsLeft = opsLeft[2:]
+
+		case _OpCodeNot:
+			top, rest := stack[len(stack)-1], stack[:len(stack)-1]
+			stack = append(rest, _span{kind: _OpCodeNot, children: []_span{top}})
+			opsLeft = opsLeft[1:]
+
+		case _OpCodeAnd, _OpCodeOr:
+			argCount := int(opsLeft[1])
+			top, rest := pickTop(stack, argCount)
+			children := append([]_span{}, top...)
+			stack = append(rest, _span{kind: opsLeft[0], children: children})
+			opsLeft = opsLeft[2:]
+		}
+	}
+
+	return stack[0]
+}
+
+// NNF rewrites s into negation normal form: Not is only ever applied
+// directly to a leaf.
+//
+// It pushes negations down via De Morgan's laws, turning Not(And(...)) into
+// Or(Not(...), ...) and vice versa, and collapses double negations. NNF is
+// idempotent and does not change what s evaluates to under the boolean
+// Evaluator.
+//
+// Since this never changes how many leafs or And/Or arguments s has, NNF
+// walks s.ops with a worker stack of spans tracking a negated flag, the way
+// combineSpecs's reencode walks an opcode stream, and writes the result
+// straight into a new ops/leafs pair instead of paying to rebuild through
+// Leaf/Not/And/Or.
+//
+// NNF panics if s is a zero specification.
+func NNF[Leaf any](s Specification[Leaf]) Specification[Leaf] {
+	if s.Zero() {
+		panic("NNF: cannot use zero spec")
+	}
+
+	root := decodeSpans(s.ops)
+
+	out := Specification[Leaf]{
+		ops:   make([]_OpCode, 0, len(s.ops)),
+		leafs: make([]Leaf, 0, len(s.leafs)),
+	}
+	emitNNF(&out, s.leafs, root, false)
+	return out
+}
+
+// emitNNF writes n - negated, if negate is set - onto out's opcode and
+// leaf streams. A negated And/Or becomes the dual op over negated
+// children (De Morgan's laws); a negated Leaf gets a trailing Not; a Not
+// node just flips negate for its one child and disappears.
+func emitNNF[Leaf any](out *Specification[Leaf], leafs []Leaf, n _span, negate bool) {
+	switch n.kind {
+
+	case _OpCodeLeaf:
+		leafIx := len(out.leafs)
+		out.leafs = append(out.leafs, leafs[n.leafIx])
+		out.ops = append(out.ops, _OpCodeLeaf, _OpCode(leafIx))
+		if negate {
+			out.ops = append(out.ops, _OpCodeNot)
+		}
+
+	case _OpCodeNot:
+		emitNNF(out, leafs, n.children[0], !negate)
+
+	case _OpCodeAnd, _OpCodeOr:
+		kind := n.kind
+		if negate {
+			kind = dual(kind)
+		}
+		for _, c := range n.children {
+			emitNNF(out, leafs, c, negate)
+		}
+		out.ops = append(out.ops, kind, _OpCode(len(n.children)))
+	}
+}
+
+// CNF rewrites s into conjunctive normal form: an And of Ors of (possibly
+// negated) leafs.
+//
+// Distributing Or over And can blow the clause count up exponentially in
+// the worst case, so CNF gives up and returns ErrTooLarge rather than
+// building more than maxClauses top-level clauses.
+//
+// CNF panics if s is a zero specification.
+func CNF[Leaf any](s Specification[Leaf], maxClauses int) (Specification[Leaf], error) {
+	if s.Zero() {
+		panic("CNF: cannot use zero spec")
+	}
+	return distributeInto(s, _OpCodeAnd, maxClauses)
+}
+
+// DNF rewrites s into disjunctive normal form: an Or of Ands of (possibly
+// negated) leafs.
+//
+// It shares its clause budget and exponential-blowup guard with CNF; see
+// its documentation for details.
+//
+// DNF panics if s is a zero specification.
+func DNF[Leaf any](s Specification[Leaf], maxClauses int) (Specification[Leaf], error) {
+	if s.Zero() {
+		panic("DNF: cannot use zero spec")
+	}
+	return distributeInto(s, _OpCodeOr, maxClauses)
+}
+
+func distributeInto[Leaf any](s Specification[Leaf], outer _OpCode, maxClauses int) (Specification[Leaf], error) {
+	clauses, err := clausesOf(decode(NNF(s)), outer, maxClauses)
+	if err != nil {
+		return Specification[Leaf]{}, err
+	}
+
+	inner := dual(outer)
+	wrapped := make([]_Node[Leaf], len(clauses))
+	for i, c := range clauses {
+		wrapped[i] = wrapClause(c, inner)
+	}
+
+	return encode(wrapClause(wrapped, outer)), nil
+}
+
+// clausesOf rewrites n, which must already be in negation normal form, into
+// a list of clauses meant to be combined with outer; each clause is itself
+// a list of literals meant to be combined with the dual of outer.
+func clausesOf[Leaf any](n _Node[Leaf], outer _OpCode, maxClauses int) ([][]_Node[Leaf], error) {
+	switch n.kind {
+
+	case _OpCodeLeaf, _OpCodeNot:
+		return [][]_Node[Leaf]{{n}}, nil
+
+	case outer:
+		out := make([][]_Node[Leaf], 0, len(n.children))
+		for _, c := range n.children {
+			sub, err := clausesOf(c, outer, maxClauses)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, sub...)
+			if len(out) > maxClauses {
+				return nil, ErrTooLarge
+			}
+		}
+		return out, nil
+
+	default: // n.kind is dual(outer): distribute it over its children's clauses
+		product := [][]_Node[Leaf]{{}}
+		for _, c := range n.children {
+			sub, err := clausesOf(c, outer, maxClauses)
+			if err != nil {
+				return nil, err
+			}
+			product, err = crossJoin(product, sub, maxClauses)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return product, nil
+	}
+}
+
+func crossJoin[Leaf any](a, b [][]_Node[Leaf], maxClauses int) ([][]_Node[Leaf], error) {
+	out := make([][]_Node[Leaf], 0, len(a)*len(b))
+	for _, ca := range a {
+		for _, cb := range b {
+			combined := make([]_Node[Leaf], 0, len(ca)+len(cb))
+			combined = append(combined, ca...)
+			combined = append(combined, cb...)
+			out = append(out, combined)
+			if len(out) > maxClauses {
+				return nil, ErrTooLarge
+			}
+		}
+	}
+	return out, nil
+}
+
+func wrapClause[Leaf any](lits []_Node[Leaf], op _OpCode) _Node[Leaf] {
+	if len(lits) == 1 {
+		return lits[0]
+	}
+	return _Node[Leaf]{kind: op, children: lits}
+}
+
+// Simplify flattens nested And-of-And and Or-of-Or, drops clauses that are
+// duplicates or are subsumed by another clause, and collapses single-child
+// And/Or back down to their one remaining child.
+//
+// equal reports whether two leafs describe the same condition; Simplify
+// uses it to compare leafs, including ones wrapped in Not, when
+// deduplicating and checking for subsumption.
+//
+// Simplify panics if s is a zero specification.
+func Simplify[Leaf any](s Specification[Leaf], equal func(a, b Leaf) bool) Specification[Leaf] {
+	if s.Zero() {
+		panic("Simplify: cannot use zero spec")
+	}
+
+	return encode(simplify(decode(s), equal))
+}
+
+func simplify[Leaf any](n _Node[Leaf], equal func(a, b Leaf) bool) _Node[Leaf] {
+	switch n.kind {
+
+	case _OpCodeLeaf:
+		return n
+
+	case _OpCodeNot:
+		return _Node[Leaf]{kind: _OpCodeNot, children: []_Node[Leaf]{simplify(n.children[0], equal)}}
+
+	case _OpCodeAnd, _OpCodeOr:
+		flat := flatten(n, equal)
+		deduped := dedupeNodes(flat, equal)
+		pruned := dropSubsumed(deduped, dual(n.kind), equal)
+		return wrapClause(pruned, n.kind)
+	}
+
+	panic("simplify: unknown node kind")
+}
+
+// flatten simplifies n's children and merges grandchildren that share n's
+// own kind, so nested And-of-And / Or-of-Or collapse into a single level.
+func flatten[Leaf any](n _Node[Leaf], equal func(a, b Leaf) bool) []_Node[Leaf] {
+	out := make([]_Node[Leaf], 0, len(n.children))
+	for _, c := range n.children {
+		c = simplify(c, equal)
+		if c.kind == n.kind {
+			out = append(out, c.children...)
+		} else {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func dedupeNodes[Leaf any](nodes []_Node[Leaf], equal func(a, b Leaf) bool) []_Node[Leaf] {
+	out := make([]_Node[Leaf], 0, len(nodes))
+	for _, n := range nodes {
+		if !containsNode(out, n, equal) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func containsNode[Leaf any](nodes []_Node[Leaf], n _Node[Leaf], equal func(a, b Leaf) bool) bool {
+	for _, o := range nodes {
+		if nodeEqual(o, n, equal) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeEqual[Leaf any](a, b _Node[Leaf], equal func(a, b Leaf) bool) bool {
+	if a.kind != b.kind {
+		return false
+	}
+
+	switch a.kind {
+
+	case _OpCodeLeaf:
+		return equal(a.leaf, b.leaf)
+
+	case _OpCodeNot:
+		return nodeEqual(a.children[0], b.children[0], equal)
+
+	default:
+		if len(a.children) != len(b.children) {
+			return false
+		}
+		for i := range a.children {
+			if !nodeEqual(a.children[i], b.children[i], equal) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// dropSubsumed removes clauses whose literals are a superset of another
+// clause's literals: in a conjunction, a ∧ (a ∨ b) is just a, so the wider
+// clause adds nothing once the narrower one is present.
+func dropSubsumed[Leaf any](clauses []_Node[Leaf], clauseOp _OpCode, equal func(a, b Leaf) bool) []_Node[Leaf] {
+	lits := make([][]_Node[Leaf], len(clauses))
+	for i, c := range clauses {
+		lits[i] = literalsOf(c, clauseOp)
+	}
+
+	out := make([]_Node[Leaf], 0, len(clauses))
+	for i, c := range clauses {
+		subsumedBy := -1
+		for j := range clauses {
+			if i == j {
+				continue
+			}
+			if len(lits[j]) < len(lits[i]) && isSubset(lits[j], lits[i], equal) {
+				subsumedBy = j
+				break
+			}
+		}
+		if subsumedBy == -1 {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func literalsOf[Leaf any](n _Node[Leaf], clauseOp _OpCode) []_Node[Leaf] {
+	if n.kind == clauseOp {
+		return n.children
+	}
+	return []_Node[Leaf]{n}
+}
+
+func isSubset[Leaf any](a, b []_Node[Leaf], equal func(a, b Leaf) bool) bool {
+	for _, la := range a {
+		found := false
+		for _, lb := range b {
+			if nodeEqual(la, lb, equal) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
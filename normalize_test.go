@@ -0,0 +1,191 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/szabba/assert"
+
+	"github.com/szabba/specifications"
+)
+
+// structureOf traces spec's shape - which opcodes appear, in what order and
+// nesting - so tests can assert structural equality instead of settling for
+// the weaker equivalence-under-Evaluate every correct rewrite already gives.
+func structureOf(spec specifications.Specification[bool]) []string {
+	v := &traceVisitor{}
+	specifications.Walk[bool](spec, v)
+	return v.trace
+}
+
+func TestNNF(t *testing.T) {
+	tts := map[string]specifications.Specification[bool]{
+		"leaf":          leaf(true),
+		"not":           not(leaf(true)),
+		"doubleNot":     not(not(leaf(true))),
+		"notOfAnd":      not(and(leaf(true), leaf(false))),
+		"notOfOr":       not(or(leaf(true), leaf(false))),
+		"nested":        and(not(or(leaf(true), not(leaf(false)))), leaf(true)),
+		"andOfAnd":      and(and(leaf(true))),
+		"reencodedNots": and(not(leaf(true))),
+	}
+
+	for name, spec := range tts {
+		t.Run(name, func(t *testing.T) {
+			// given
+			want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+
+			// when
+			nnf := specifications.NNF(spec)
+			got := specifications.Evaluate[bool, bool](nnf, Evaluator{})
+
+			// then
+			assert.That(got == want, t.Errorf, "NNF changed the value from %v to %v", want, got)
+		})
+
+		t.Run(name+"/idempotent", func(t *testing.T) {
+			// given
+			once := specifications.NNF(spec)
+
+			// when
+			twice := specifications.NNF(once)
+
+			// then
+			assert.That(
+				reflect.DeepEqual(structureOf(once), structureOf(twice)),
+				t.Errorf, "NNF(NNF(x)) is not structurally identical to NNF(x): got %v, want %v",
+				structureOf(twice), structureOf(once))
+		})
+	}
+}
+
+func TestNNFPanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[bool]
+
+	// when
+	p := catchPanic(func() { specifications.NNF(zero) })
+
+	// then
+	msg := "NNF: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
+
+func TestCNFAndDNF(t *testing.T) {
+	tts := map[string]specifications.Specification[bool]{
+		"leaf":     leaf(true),
+		"not":      not(leaf(false)),
+		"and":      and(leaf(true), leaf(false)),
+		"or":       or(leaf(true), leaf(false)),
+		"orOfAnds": or(and(leaf(true), leaf(false)), and(leaf(true), leaf(true))),
+		"andOfOrs": and(or(leaf(true), leaf(false)), or(leaf(false), leaf(true))),
+
+		// These cases are there to provide coverage for a flat clause
+		// where Not wraps an operand after the first one, which is
+		// exactly the shape CNF/DNF routinely produce.
+		"flatOrWithTrailingNot":  or(leaf(true), not(leaf(false))),
+		"flatAndWithTrailingNot": and(leaf(true), not(leaf(false))),
+	}
+
+	for name, spec := range tts {
+		t.Run(name+"/CNF", func(t *testing.T) {
+			// given
+			want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+
+			// when
+			cnf, err := specifications.CNF(spec, 100)
+
+			// then
+			assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+			got := specifications.Evaluate[bool, bool](cnf, Evaluator{})
+			assert.That(got == want, t.Errorf, "CNF changed the value from %v to %v", want, got)
+		})
+
+		t.Run(name+"/DNF", func(t *testing.T) {
+			// given
+			want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+
+			// when
+			dnf, err := specifications.DNF(spec, 100)
+
+			// then
+			assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+			got := specifications.Evaluate[bool, bool](dnf, Evaluator{})
+			assert.That(got == want, t.Errorf, "DNF changed the value from %v to %v", want, got)
+		})
+	}
+}
+
+func TestCNFReturnsErrTooLargeWhenClauseBudgetExceeded(t *testing.T) {
+	// given
+	spec := or(
+		and(leaf(true), leaf(false)),
+		and(leaf(true), leaf(false)),
+		and(leaf(true), leaf(false)),
+	)
+
+	// when
+	_, err := specifications.CNF(spec, 2)
+
+	// then
+	assert.That(err == specifications.ErrTooLarge, t.Errorf, "got %v error, not ErrTooLarge", err)
+}
+
+func TestSimplify(t *testing.T) {
+	equal := func(a, b bool) bool { return a == b }
+
+	t.Run("flattensNestedAnd", func(t *testing.T) {
+		// given
+		spec := and(and(leaf(true), leaf(false)), leaf(true))
+
+		// when
+		simplified := specifications.Simplify(spec, equal)
+
+		// then
+		want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+		got := specifications.Evaluate[bool, bool](simplified, Evaluator{})
+		assert.That(got == want, t.Errorf, "Simplify changed the value from %v to %v", want, got)
+	})
+
+	t.Run("dedupesIdenticalClauses", func(t *testing.T) {
+		// given
+		spec := or(leaf(true), leaf(true), leaf(false))
+
+		// when
+		simplified := specifications.Simplify(spec, equal)
+
+		// then
+		want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+		got := specifications.Evaluate[bool, bool](simplified, Evaluator{})
+		assert.That(got == want, t.Errorf, "Simplify changed the value from %v to %v", want, got)
+	})
+
+	t.Run("dropsSubsumedClauses", func(t *testing.T) {
+		// given: x ∧ (x ∨ y) is equivalent to x
+		spec := and(leaf(true), or(leaf(true), leaf(false)))
+
+		// when
+		simplified := specifications.Simplify(spec, equal)
+
+		// then
+		want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+		got := specifications.Evaluate[bool, bool](simplified, Evaluator{})
+		assert.That(got == want, t.Errorf, "Simplify changed the value from %v to %v", want, got)
+	})
+}
+
+func TestSimplifyPanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[bool]
+
+	// when
+	p := catchPanic(func() { specifications.Simplify(zero, func(a, b bool) bool { return a == b }) })
+
+	// then
+	msg := "Simplify: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
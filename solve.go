@@ -0,0 +1,348 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnknown is returned by SolveWithOptions when SolverOptions.Timeout
+// elapsed before the search could either find all solutions or prove there
+// are none.
+var ErrUnknown = errors.New("specifications: could not solve within the given bounds")
+
+// A Domain tells the solver how to recognize when two leafs describe the
+// same underlying proposition, so it solves for each distinct one only
+// once.
+type Domain[Leaf any] interface {
+
+	// Equal reports whether a and b describe the same proposition.
+	Equal(a, b Leaf) bool
+
+	// Key returns a comparable value that is equal for any two leafs Equal
+	// considers equal.
+	Key(Leaf) any
+}
+
+// A LeafValue pairs a leaf with the boolean value an Assignment gives it.
+type LeafValue[Leaf any] struct {
+	Leaf  Leaf
+	Value bool
+}
+
+// An Assignment gives a boolean value to every distinct leaf a Specification
+// was solved over.
+type Assignment[Leaf any] []LeafValue[Leaf]
+
+// Value looks up the value leaf was given, using dom to match it against
+// the leafs in the assignment. The second result is false if leaf is not
+// part of the assignment.
+func (a Assignment[Leaf]) Value(leaf Leaf, dom Domain[Leaf]) (bool, bool) {
+	key := dom.Key(leaf)
+	for _, lv := range a {
+		if dom.Key(lv.Leaf) == key {
+			return lv.Value, true
+		}
+	}
+	return false, false
+}
+
+// SolverOptions bounds the work SolveWithOptions is willing to do.
+//
+// The zero value imposes no limit and no timeout.
+type SolverOptions struct {
+
+	// Limit caps the number of assignments Solve returns. Zero means no
+	// cap.
+	Limit int
+
+	// Timeout bounds how long solving may run. Zero means no bound.
+	Timeout time.Duration
+}
+
+const _defaultMaxClauses = 1 << 20
+
+// Solve finds every assignment of spec's distinct leafs that makes it
+// evaluate to true under the boolean Evaluator, using dom to recognize when
+// two leafs are the same proposition.
+//
+// An empty, non-nil result means spec is unsatisfiable. Solve is equivalent
+// to calling SolveWithOptions with the zero SolverOptions.
+//
+// Solve panics if spec is a zero specification.
+func Solve[Leaf any](spec Specification[Leaf], dom Domain[Leaf]) ([]Assignment[Leaf], error) {
+	if spec.Zero() {
+		panic("Solve: cannot use zero spec")
+	}
+	return SolveWithOptions(spec, dom, SolverOptions{})
+}
+
+// SolveOne finds one assignment of spec's distinct leafs that makes it
+// evaluate to true, or reports that none exists.
+//
+// SolveOne panics if spec is a zero specification.
+func SolveOne[Leaf any](spec Specification[Leaf], dom Domain[Leaf]) (Assignment[Leaf], bool, error) {
+	if spec.Zero() {
+		panic("SolveOne: cannot use zero spec")
+	}
+	sols, err := SolveWithOptions(spec, dom, SolverOptions{Limit: 1})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(sols) == 0 {
+		return nil, false, nil
+	}
+	return sols[0], true, nil
+}
+
+// SolveWithOptions is Solve with explicit bounds on the search; see
+// SolverOptions.
+//
+// SolveWithOptions panics if spec is a zero specification.
+func SolveWithOptions[Leaf any](spec Specification[Leaf], dom Domain[Leaf], opts SolverOptions) ([]Assignment[Leaf], error) {
+	if spec.Zero() {
+		panic("SolveWithOptions: cannot use zero spec")
+	}
+
+	cnf, err := CNF(spec, _defaultMaxClauses)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, clauses := cnfToClauses(cnf, dom)
+
+	s := &_dpll{
+		clauses: clauses,
+		nVars:   len(vars),
+		limit:   opts.Limit,
+	}
+	if opts.Timeout > 0 {
+		s.deadline = time.Now().Add(opts.Timeout)
+	}
+
+	s.search(make([]int8, len(vars)))
+
+	if s.timedOut {
+		return nil, ErrUnknown
+	}
+
+	out := make([]Assignment[Leaf], len(s.solutions))
+	for i, values := range s.solutions {
+		a := make(Assignment[Leaf], len(vars))
+		for v, leaf := range vars {
+			a[v] = LeafValue[Leaf]{Leaf: leaf, Value: values[v] > 0}
+		}
+		out[i] = a
+	}
+	return out, nil
+}
+
+// cnfToClauses turns a CNF specification into a DPLL-friendly clause list:
+// each variable gets a 0-based index, and each literal is that index plus
+// one, negated for a negative literal.
+func cnfToClauses[Leaf any](cnf Specification[Leaf], dom Domain[Leaf]) ([]Leaf, [][]int) {
+	keyToVar := make(map[any]int)
+	var vars []Leaf
+
+	varFor := func(leaf Leaf) int {
+		key := dom.Key(leaf)
+		if v, ok := keyToVar[key]; ok {
+			return v
+		}
+		v := len(vars)
+		keyToVar[key] = v
+		vars = append(vars, leaf)
+		return v
+	}
+
+	var literalOf func(_Node[Leaf]) int
+	literalOf = func(n _Node[Leaf]) int {
+		switch n.kind {
+		case _OpCodeLeaf:
+			return varFor(n.leaf) + 1
+		case _OpCodeNot:
+			return -literalOf(n.children[0])
+		}
+		panic("cnfToClauses: expected a literal")
+	}
+
+	clauseOf := func(n _Node[Leaf]) []int {
+		if n.kind == _OpCodeOr {
+			lits := make([]int, len(n.children))
+			for i, c := range n.children {
+				lits[i] = literalOf(c)
+			}
+			return lits
+		}
+		return []int{literalOf(n)}
+	}
+
+	root := decode(cnf)
+
+	var clauses [][]int
+	if root.kind == _OpCodeAnd {
+		clauses = make([][]int, len(root.children))
+		for i, c := range root.children {
+			clauses[i] = clauseOf(c)
+		}
+	} else {
+		clauses = [][]int{clauseOf(root)}
+	}
+
+	return vars, clauses
+}
+
+// _dpll runs the Davis-Putnam-Logemann-Loveland algorithm: unit
+// propagation to shrink the clause list, then branching on the next
+// unassigned variable, backtracking on conflict. It does no pure-literal
+// elimination; see propagate.
+type _dpll struct {
+	clauses   [][]int
+	nVars     int
+	limit     int
+	deadline  time.Time
+	solutions [][]int8
+	timedOut  bool
+}
+
+// search explores every completion of assign, recording solutions, and
+// stops early once limit solutions are found or the deadline passes.
+func (s *_dpll) search(assign []int8) (stop bool) {
+	if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		s.timedOut = true
+		return true
+	}
+
+	reduced, ok := propagate(s.clauses, assign)
+	if !ok {
+		return false
+	}
+
+	if len(reduced) == 0 {
+		return s.recordCompletions(assign)
+	}
+
+	v := reduced[0][0]
+	if v < 0 {
+		v = -v
+	}
+	v--
+
+	for _, val := range [2]int8{1, -1} {
+		next := append([]int8(nil), assign...)
+		next[v] = val
+		if s.search(next) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCompletions records assign as a solution, enumerating every way of
+// filling in the variables it leaves unassigned (they're free: the
+// specification is satisfied no matter what they are).
+func (s *_dpll) recordCompletions(assign []int8) (stop bool) {
+	free := -1
+	for v, a := range assign {
+		if a == 0 {
+			free = v
+			break
+		}
+	}
+
+	if free == -1 {
+		s.solutions = append(s.solutions, append([]int8(nil), assign...))
+		return s.limit > 0 && len(s.solutions) >= s.limit
+	}
+
+	for _, val := range [2]int8{1, -1} {
+		next := append([]int8(nil), assign...)
+		next[free] = val
+		if s.recordCompletions(next) {
+			return true
+		}
+	}
+	return false
+}
+
+// propagate applies unit propagation to clauses given the (mutated in
+// place) partial assignment in assign, until no unit clause is left. It
+// returns the remaining, satisfied-clause free clause list, and false if a
+// clause became empty (a conflict).
+//
+// It deliberately does not do pure-literal elimination: that pins a
+// variable to the one value that keeps it pure, which is correct for
+// finding a single witness but unsound when search is meant to enumerate
+// every satisfying assignment, since it would never explore the other
+// value for that variable.
+func propagate(clauses [][]int, assign []int8) ([][]int, bool) {
+	for {
+		reduced, ok := reduce(clauses, assign)
+		if !ok {
+			return nil, false
+		}
+		clauses = reduced
+
+		v, val, found := findUnit(clauses)
+		if !found {
+			return clauses, true
+		}
+		assign[v] = val
+	}
+}
+
+// reduce drops clauses already satisfied by assign, and drops falsified
+// literals from the rest. It returns false if a clause is left with no
+// literals, meaning assign contradicts it.
+func reduce(clauses [][]int, assign []int8) ([][]int, bool) {
+	out := make([][]int, 0, len(clauses))
+
+	for _, clause := range clauses {
+		satisfied := false
+		remaining := make([]int, 0, len(clause))
+
+		for _, lit := range clause {
+			v, val := litVar(lit)
+			switch assign[v] {
+			case 0:
+				remaining = append(remaining, lit)
+			case val:
+				satisfied = true
+			}
+		}
+
+		if satisfied {
+			continue
+		}
+		if len(remaining) == 0 {
+			return nil, false
+		}
+		out = append(out, remaining)
+	}
+
+	return out, true
+}
+
+// findUnit looks for a clause with exactly one (necessarily unassigned)
+// literal left, and reports the assignment that would satisfy it.
+func findUnit(clauses [][]int) (v int, val int8, found bool) {
+	for _, clause := range clauses {
+		if len(clause) == 1 {
+			v, val = litVar(clause[0])
+			return v, val, true
+		}
+	}
+	return 0, 0, false
+}
+
+// litVar splits a literal into its 0-based variable index and the value
+// that would satisfy it.
+func litVar(lit int) (v int, val int8) {
+	if lit < 0 {
+		return -lit - 1, -1
+	}
+	return lit - 1, 1
+}
@@ -0,0 +1,209 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications_test
+
+import (
+	"testing"
+
+	"github.com/szabba/assert"
+
+	"github.com/szabba/specifications"
+)
+
+type stringDomain struct{}
+
+func (stringDomain) Equal(a, b string) bool { return a == b }
+
+func (stringDomain) Key(s string) any { return s }
+
+var (
+	sleaf = specifications.Leaf[string]
+	snot  = specifications.Not[string]
+	sand  = specifications.And[string]
+	sor   = specifications.Or[string]
+)
+
+func allSatisfy(t *testing.T, spec specifications.Specification[string], sols []specifications.Assignment[string]) {
+	t.Helper()
+
+	dom := stringDomain{}
+	for _, sol := range sols {
+		out := specifications.Evaluate[string, bool](spec, assignEvaluator{sol, dom})
+		assert.That(out, t.Errorf, "assignment %#v does not satisfy the specification", sol)
+	}
+}
+
+type assignEvaluator struct {
+	assignment specifications.Assignment[string]
+	dom        specifications.Domain[string]
+}
+
+func (e assignEvaluator) EvaluateLeaf(l string) bool {
+	v, _ := e.assignment.Value(l, e.dom)
+	return v
+}
+
+func (e assignEvaluator) EvaluateNot(v bool) bool { return !v }
+
+func (e assignEvaluator) EvaluateAnd(vs []bool) bool {
+	out := true
+	for _, v := range vs {
+		out = out && v
+	}
+	return out
+}
+
+func (e assignEvaluator) EvaluateOr(vs []bool) bool {
+	out := false
+	for _, v := range vs {
+		out = out || v
+	}
+	return out
+}
+
+func TestSolveTautology(t *testing.T) {
+	// given
+	spec := sor(sleaf("x"), snot(sleaf("x")))
+
+	// when
+	sols, err := specifications.Solve[string](spec, stringDomain{})
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(sols) == 2, t.Errorf, "got %d solutions, want 2", len(sols))
+	allSatisfy(t, spec, sols)
+}
+
+func TestSolveOrIsComplete(t *testing.T) {
+	// given: Or(x, y) is satisfied by every assignment except x=false,y=false
+	spec := sor(sleaf("x"), sleaf("y"))
+
+	// when
+	sols, err := specifications.Solve[string](spec, stringDomain{})
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(sols) == 3, t.Errorf, "got %d solutions, want 3", len(sols))
+	allSatisfy(t, spec, sols)
+}
+
+func TestSolveMultiVariableTautologyIsComplete(t *testing.T) {
+	// given: a tautology over 2 distinct variables is satisfied by every
+	// one of their 2^2 assignments
+	spec := sor(sor(sleaf("x"), snot(sleaf("x"))), sor(sleaf("y"), snot(sleaf("y"))))
+
+	// when
+	sols, err := specifications.Solve[string](spec, stringDomain{})
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(sols) == 4, t.Errorf, "got %d solutions, want 2^2 = 4", len(sols))
+	allSatisfy(t, spec, sols)
+}
+
+func TestSolveContradiction(t *testing.T) {
+	// given
+	spec := sand(sleaf("x"), snot(sleaf("x")))
+
+	// when
+	sols, err := specifications.Solve[string](spec, stringDomain{})
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(sols) == 0, t.Errorf, "got %d solutions, want 0", len(sols))
+}
+
+func TestSolveReencodedNestedAnd(t *testing.T) {
+	// given
+	spec := sand(sand(sleaf("x")))
+
+	// when
+	sols, err := specifications.Solve[string](spec, stringDomain{})
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(sols) == 1, t.Errorf, "got %d solutions, want 1", len(sols))
+	allSatisfy(t, spec, sols)
+
+	v, ok := sols[0].Value("x", stringDomain{})
+	assert.That(ok, t.Errorf, "solution does not cover leaf %q", "x")
+	assert.That(v, t.Errorf, "x should be true, got false")
+}
+
+func TestSolveOne(t *testing.T) {
+	t.Run("satisfiable", func(t *testing.T) {
+		// given
+		spec := sor(sleaf("x"), sleaf("y"))
+
+		// when
+		sol, ok, err := specifications.SolveOne[string](spec, stringDomain{})
+
+		// then
+		assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+		assert.That(ok, t.Fatalf, "expected a solution to be found")
+		allSatisfy(t, spec, []specifications.Assignment[string]{sol})
+	})
+
+	t.Run("unsatisfiable", func(t *testing.T) {
+		// given
+		spec := sand(sleaf("x"), snot(sleaf("x")))
+
+		// when
+		_, ok, err := specifications.SolveOne[string](spec, stringDomain{})
+
+		// then
+		assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+		assert.That(!ok, t.Errorf, "expected no solution to be found")
+	})
+}
+
+func TestSolveWithOptionsLimit(t *testing.T) {
+	// given
+	spec := sor(sleaf("x"), sleaf("y"), sleaf("z"))
+
+	// when
+	sols, err := specifications.SolveWithOptions[string](spec, stringDomain{}, specifications.SolverOptions{Limit: 2})
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(sols) == 2, t.Errorf, "got %d solutions, want 2", len(sols))
+	allSatisfy(t, spec, sols)
+}
+
+func TestSolvePanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[string]
+
+	// when
+	p := catchPanic(func() { specifications.Solve[string](zero, stringDomain{}) })
+
+	// then
+	msg := "Solve: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
+
+func TestSolveOnePanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[string]
+
+	// when
+	p := catchPanic(func() { specifications.SolveOne[string](zero, stringDomain{}) })
+
+	// then
+	msg := "SolveOne: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
+
+func TestSolveWithOptionsPanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[string]
+
+	// when
+	p := catchPanic(func() { specifications.SolveWithOptions[string](zero, stringDomain{}, specifications.SolverOptions{}) })
+
+	// then
+	msg := "SolveWithOptions: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
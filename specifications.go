@@ -189,7 +189,7 @@ func Evaluate[Leaf, Output any](
 			opsLeft = opsLeft[2:]
 
 		case _OpCodeNot:
-			top, rest := stack[0], stack[:len(stack)-1]
+			top, rest := stack[len(stack)-1], stack[:len(stack)-1]
 			out := ev.EvaluateNot(top)
 			stack = append(rest, out)
 
@@ -0,0 +1,149 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications
+
+// A Visitor receives callbacks as Walk traverses a Specification.
+//
+// The Enter/Exit pairs bracket the traversal of an And/Or/Not's children,
+// so a Visitor can track nesting (e.g. for indentation) without having to
+// build its own stack.
+type Visitor[Leaf any] interface {
+
+	// EnterAnd is called before Walk visits an And's argCount children.
+	EnterAnd(argCount int)
+
+	// ExitAnd is called after Walk has visited an And's children.
+	ExitAnd()
+
+	// EnterOr is called before Walk visits an Or's argCount children.
+	EnterOr(argCount int)
+
+	// ExitOr is called after Walk has visited an Or's children.
+	ExitOr()
+
+	// EnterNot is called before Walk visits a Not's wrapped specification.
+	EnterNot()
+
+	// ExitNot is called after Walk has visited a Not's wrapped specification.
+	ExitNot()
+
+	// VisitLeaf is called for each leaf, in the order they appear in s.
+	VisitLeaf(Leaf)
+}
+
+// Walk traverses s depth-first, calling v's hooks as it enters and leaves
+// each And, Or and Not, and for every leaf it visits.
+//
+// Walk panics if s is a zero specification.
+func Walk[Leaf any](s Specification[Leaf], v Visitor[Leaf]) {
+	if s.Zero() {
+		panic("Walk: cannot use zero spec")
+	}
+	walk(decode(s), v)
+}
+
+func walk[Leaf any](n _Node[Leaf], v Visitor[Leaf]) {
+	switch n.kind {
+
+	case _OpCodeLeaf:
+		v.VisitLeaf(n.leaf)
+
+	case _OpCodeNot:
+		v.EnterNot()
+		walk(n.children[0], v)
+		v.ExitNot()
+
+	case _OpCodeAnd:
+		v.EnterAnd(len(n.children))
+		for _, c := range n.children {
+			walk(c, v)
+		}
+		v.ExitAnd()
+
+	case _OpCodeOr:
+		v.EnterOr(len(n.children))
+		for _, c := range n.children {
+			walk(c, v)
+		}
+		v.ExitOr()
+	}
+}
+
+// MapLeaves rewrites s into a Specification[To] by turning every leaf into
+// a To via f. The structure of s - which leafs are negated and how they're
+// combined - is left untouched.
+//
+// MapLeaves does not rebuild through Leaf/Not/And/Or: since f can't change
+// the shape of s, it copies the opcode stream as-is and only re-encodes the
+// leafs.
+//
+// MapLeaves panics if s is a zero specification.
+func MapLeaves[From, To any](s Specification[From], f func(From) To) Specification[To] {
+	if s.Zero() {
+		panic("MapLeaves: cannot use zero spec")
+	}
+
+	leafs := make([]To, len(s.leafs))
+	for i, leaf := range s.leafs {
+		leafs[i] = f(leaf)
+	}
+
+	return Specification[To]{
+		ops:   append([]_OpCode{}, s.ops...),
+		leafs: leafs,
+	}
+}
+
+// Filter rebuilds s keeping only the leafs for which keep returns true,
+// collapsing And/Or to their surviving children and dropping a Not whose
+// wrapped specification disappears entirely.
+//
+// It returns a zero specification and false if nothing survives.
+//
+// Filter panics if s is a zero specification.
+func Filter[Leaf any](s Specification[Leaf], keep func(Leaf) bool) (Specification[Leaf], bool) {
+	if s.Zero() {
+		panic("Filter: cannot use zero spec")
+	}
+
+	n, ok := filterNode(decode(s), keep)
+	if !ok {
+		var zero Specification[Leaf]
+		return zero, false
+	}
+	return encode(n), true
+}
+
+func filterNode[Leaf any](n _Node[Leaf], keep func(Leaf) bool) (_Node[Leaf], bool) {
+	switch n.kind {
+
+	case _OpCodeLeaf:
+		if !keep(n.leaf) {
+			return _Node[Leaf]{}, false
+		}
+		return n, true
+
+	case _OpCodeNot:
+		child, ok := filterNode(n.children[0], keep)
+		if !ok {
+			return _Node[Leaf]{}, false
+		}
+		return _Node[Leaf]{kind: _OpCodeNot, children: []_Node[Leaf]{child}}, true
+
+	case _OpCodeAnd, _OpCodeOr:
+		var children []_Node[Leaf]
+		for _, c := range n.children {
+			if fc, ok := filterNode(c, keep); ok {
+				children = append(children, fc)
+			}
+		}
+		if len(children) == 0 {
+			return _Node[Leaf]{}, false
+		}
+		return _Node[Leaf]{kind: n.kind, children: children}, true
+	}
+
+	panic("filterNode: unknown node kind")
+}
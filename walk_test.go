@@ -0,0 +1,178 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package specifications_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/szabba/assert"
+
+	"github.com/szabba/specifications"
+)
+
+type traceVisitor struct {
+	trace []string
+}
+
+func (v *traceVisitor) EnterAnd(argCount int) {
+	v.trace = append(v.trace, fmt.Sprintf("And(%d)", argCount))
+}
+func (v *traceVisitor) ExitAnd() { v.trace = append(v.trace, ")") }
+func (v *traceVisitor) EnterOr(argCount int) {
+	v.trace = append(v.trace, fmt.Sprintf("Or(%d)", argCount))
+}
+func (v *traceVisitor) ExitOr()          { v.trace = append(v.trace, ")") }
+func (v *traceVisitor) EnterNot()        { v.trace = append(v.trace, "Not(") }
+func (v *traceVisitor) ExitNot()         { v.trace = append(v.trace, ")") }
+func (v *traceVisitor) VisitLeaf(l bool) { v.trace = append(v.trace, fmt.Sprint(l)) }
+
+func TestWalkVisitsInPrePostOrder(t *testing.T) {
+	// given
+	spec := and(leaf(true), not(leaf(false)))
+	v := &traceVisitor{}
+
+	// when
+	specifications.Walk[bool](spec, v)
+
+	// then
+	want := []string{"And(2)", "true", "Not(", "false", ")", ")"}
+	assert.That(
+		fmt.Sprint(v.trace) == fmt.Sprint(want),
+		t.Errorf, "got trace %v, want %v", v.trace, want)
+}
+
+func TestWalkPanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[bool]
+
+	// when
+	p := catchPanic(func() { specifications.Walk[bool](zero, &traceVisitor{}) })
+
+	// then
+	msg := "Walk: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
+
+func TestMapLeavesPreservesValueUnderIdentity(t *testing.T) {
+	tts := map[string]specifications.Specification[bool]{
+		"leaf":          leaf(true),
+		"not":           not(leaf(false)),
+		"and":           and(leaf(true), leaf(false)),
+		"or":            or(leaf(true), leaf(false)),
+		"reencodedNots": and(not(leaf(true))),
+	}
+
+	for name, spec := range tts {
+		t.Run(name, func(t *testing.T) {
+			// given
+			want := specifications.Evaluate[bool, bool](spec, Evaluator{})
+
+			// when
+			mapped := specifications.MapLeaves(spec, func(v bool) bool { return v })
+
+			// then
+			got := specifications.Evaluate[bool, bool](mapped, Evaluator{})
+			assert.That(got == want, t.Errorf, "MapLeaves changed the value from %v to %v", want, got)
+		})
+	}
+}
+
+func TestMapLeavesChangesTheLeafType(t *testing.T) {
+	// given
+	spec := not(leaf(false))
+
+	// when
+	mapped := specifications.MapLeaves(spec, func(v bool) string {
+		if v {
+			return "yes"
+		}
+		return "no"
+	})
+
+	// then
+	out := specifications.Evaluate[string, string](mapped, ToStringEvaluator{})
+	want := "!no"
+	assert.That(out == want, t.Errorf, "got %q, want %q", out, want)
+}
+
+func TestMapLeavesPanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[bool]
+
+	// when
+	p := catchPanic(func() { specifications.MapLeaves(zero, func(v bool) bool { return v }) })
+
+	// then
+	msg := "MapLeaves: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}
+
+type ToStringEvaluator struct{}
+
+func (ToStringEvaluator) EvaluateLeaf(v string) string { return v }
+
+func (ToStringEvaluator) EvaluateNot(v string) string { return "!" + v }
+
+func (ToStringEvaluator) EvaluateAnd(vs []string) string {
+	return "(" + vs[0] + " && " + vs[1] + ")"
+}
+
+func (ToStringEvaluator) EvaluateOr(vs []string) string {
+	return "(" + vs[0] + " || " + vs[1] + ")"
+}
+
+func TestFilter(t *testing.T) {
+	keepTrue := func(v bool) bool { return v }
+
+	t.Run("dropsFilteredOutLeafFromAnd", func(t *testing.T) {
+		// given
+		spec := and(leaf(true), leaf(false), leaf(true))
+
+		// when
+		filtered, ok := specifications.Filter(spec, keepTrue)
+
+		// then
+		assert.That(ok, t.Fatalf, "expected a surviving specification")
+		out := specifications.Evaluate[bool, bool](filtered, Evaluator{})
+		assert.That(out, t.Errorf, "filtered spec should evaluate true, got %v", out)
+	})
+
+	t.Run("dropsWholeNotWhenItsLeafIsFiltered", func(t *testing.T) {
+		// given
+		spec := and(leaf(true), not(leaf(false)))
+
+		// when
+		filtered, ok := specifications.Filter(spec, keepTrue)
+
+		// then
+		assert.That(ok, t.Fatalf, "expected a surviving specification")
+		out := specifications.Evaluate[bool, bool](filtered, Evaluator{})
+		assert.That(out, t.Errorf, "filtered spec should evaluate true, got %v", out)
+	})
+
+	t.Run("returnsFalseWhenNothingSurvives", func(t *testing.T) {
+		// given
+		spec := and(leaf(false), not(leaf(false)))
+
+		// when
+		_, ok := specifications.Filter(spec, keepTrue)
+
+		// then
+		assert.That(!ok, t.Errorf, "expected no specification to survive")
+	})
+}
+
+func TestFilterPanicsWhenGivenAZeroSpec(t *testing.T) {
+	// given
+	var zero specifications.Specification[bool]
+
+	// when
+	p := catchPanic(func() { specifications.Filter(zero, func(v bool) bool { return v }) })
+
+	// then
+	msg := "Filter: cannot use zero spec"
+	assert.That(p == msg, t.Errorf, "got %#v panic, not %q", p, msg)
+}